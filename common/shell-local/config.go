@@ -0,0 +1,143 @@
+package shell_local
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer/template/interpolate"
+)
+
+// Config is the configuration structure for the shell-local provisioner and
+// post-processor. It is shared so that both callers get identical script
+// execution, templating and validation behavior.
+type Config struct {
+	ctx interpolate.Context
+
+	// Command(s) to execute. These can be one or more inline scripts, or a
+	// set of script files to run in order.
+	Inline  []string `mapstructure:"inline"`
+	Scripts []string `mapstructure:"scripts"`
+
+	// InlineShebang is prepended to any inline script before it is written
+	// to a temporary file for execution.
+	InlineShebang string `mapstructure:"inline_shebang"`
+
+	// TempfileExtension is appended to the generated inline script's
+	// filename, so it can be picked up by an interpreter that keys off of
+	// extension (e.g. ".ps1").
+	TempfileExtension string `mapstructure:"tempfile_extension"`
+
+	// Vars is a list of "key=value" strings that are exposed to the script
+	// as environment variables, in addition to the Packer-provided ones.
+	Vars []string `mapstructure:"environment_vars"`
+
+	// EnvVarFormat is the fmt verb pair used to assemble each environment
+	// variable assignment before it is prepended to the command line.
+	EnvVarFormat string
+
+	// ExecuteCommand is the command template used to invoke each script.
+	ExecuteCommand []string `mapstructure:"execute_command"`
+
+	// ValidExitCodes is the set of exit codes that are considered
+	// successful. Defaults to []int{0}.
+	ValidExitCodes []int `mapstructure:"valid_exit_codes"`
+
+	// OnlyOn restricts execution of shell-local to the given runtime.GOOS
+	// values.
+	OnlyOn []string `mapstructure:"only_on"`
+
+	// LogFormat selects the encoding used when LogFile is set: "text" or
+	// "json" (the default). It has no effect on the UI stream, which is
+	// always plain text.
+	LogFormat string `mapstructure:"log_format"`
+
+	// LogFile, if set, receives a copy of every line of script output,
+	// encoded per LogFormat, in addition to the normal UI stream.
+	LogFile string `mapstructure:"log_file"`
+
+	// SyslogAddr, if set, receives a copy of every line of script output
+	// via RFC5424 syslog. The special value "local" connects to the local
+	// syslog/journald socket; any other value is dialed over udp as
+	// "host:port".
+	SyslogAddr string `mapstructure:"syslog_address"`
+
+	// MaxRetries is the number of additional attempts made after a script
+	// exits with a retryable code. Zero (the default) disables retries.
+	MaxRetries int `mapstructure:"max_retries"`
+
+	// RetryBackoff is the delay before the first retry. Each subsequent
+	// retry doubles the previous delay, up to RetryBackoffMax.
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+
+	// RetryBackoffMax caps the exponential backoff delay between retries.
+	RetryBackoffMax time.Duration `mapstructure:"retry_backoff_max"`
+
+	// RetryOnExitCodes is the set of script exit codes that trigger a
+	// retry. If empty, any exit code considered invalid by ValidExitCode
+	// is retried.
+	RetryOnExitCodes []int `mapstructure:"retry_on_exit_codes"`
+
+	// RetryTimeout bounds the total time spent on a script across its
+	// initial attempt and all retries. Zero means no overall timeout.
+	RetryTimeout time.Duration `mapstructure:"retry_timeout"`
+
+	// Parallelism is the number of scripts that may run at once. Values
+	// less than 2 (the default) run scripts sequentially, in order.
+	Parallelism int `mapstructure:"parallelism"`
+
+	// OutputMode controls how concurrently-running scripts' output is
+	// presented when Parallelism > 1: "buffered" (the default) holds each
+	// script's output until it finishes, then flushes it as one block, so
+	// output stays readable; "interleaved" writes every line immediately,
+	// tagged with its script name.
+	OutputMode string `mapstructure:"output_mode"`
+
+	// Secrets lists values to resolve once before the first script runs.
+	// Each resolved value is exposed to every script as the named
+	// environment variable and registered with packer.LogSecretFilter so
+	// it's scrubbed from all UI and log output.
+	Secrets []SecretSource `mapstructure:"secrets"`
+
+	// DryRun, if true, renders every script's environment and final
+	// command line but never invokes the communicator. Useful for
+	// reviewing what a provisioner change would actually run.
+	DryRun bool `mapstructure:"dry_run"`
+
+	// DryRunOutputPath is where the rendered dry-run output is written.
+	// If empty, it's written to the UI instead.
+	DryRunOutputPath string `mapstructure:"dry_run_output_path"`
+
+	PackerBuildName   string
+	PackerBuilderType string
+}
+
+// isRetryableExitCode reports whether a script that exited with the given
+// code should be retried. If RetryOnExitCodes is empty, any exit code that
+// ValidExitCode rejects is considered retryable.
+func (c *Config) isRetryableExitCode(exitcode int) bool {
+	if len(c.RetryOnExitCodes) == 0 {
+		return c.ValidExitCode(exitcode) != nil
+	}
+	for _, code := range c.RetryOnExitCodes {
+		if code == exitcode {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) ValidExitCode(exitcode int) error {
+	if len(c.ValidExitCodes) == 0 {
+		if exitcode != 0 {
+			return fmt.Errorf("Script exited with non-zero exit status: %d", exitcode)
+		}
+		return nil
+	}
+
+	for _, code := range c.ValidExitCodes {
+		if code == exitcode {
+			return nil
+		}
+	}
+	return fmt.Errorf("Script exited with non-zero exit status: %d. Allowed exit codes are: %v", exitcode, c.ValidExitCodes)
+}
@@ -0,0 +1,67 @@
+package shell_local
+
+import "testing"
+
+func TestConfig_isRetryableExitCode(t *testing.T) {
+	cases := []struct {
+		name             string
+		validExitCodes   []int
+		retryOnExitCodes []int
+		exitcode         int
+		want             bool
+	}{
+		{
+			name:     "no retry list, default valid code 0, failure retried",
+			exitcode: 1,
+			want:     true,
+		},
+		{
+			name:     "no retry list, default valid code 0, success not retried",
+			exitcode: 0,
+			want:     false,
+		},
+		{
+			name:           "no retry list, custom valid codes, listed code not retried",
+			validExitCodes: []int{0, 2},
+			exitcode:       2,
+			want:           false,
+		},
+		{
+			name:           "no retry list, custom valid codes, unlisted code retried",
+			validExitCodes: []int{0, 2},
+			exitcode:       1,
+			want:           true,
+		},
+		{
+			name:             "explicit retry list, matching code retried",
+			retryOnExitCodes: []int{75, 130},
+			exitcode:         75,
+			want:             true,
+		},
+		{
+			name:             "explicit retry list, non-matching failure not retried",
+			retryOnExitCodes: []int{75, 130},
+			exitcode:         1,
+			want:             false,
+		},
+		{
+			name:             "explicit retry list takes precedence even for otherwise-valid codes",
+			validExitCodes:   []int{0},
+			retryOnExitCodes: []int{0},
+			exitcode:         0,
+			want:             true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{
+				ValidExitCodes:   tc.validExitCodes,
+				RetryOnExitCodes: tc.retryOnExitCodes,
+			}
+			if got := c.isRetryableExitCode(tc.exitcode); got != tc.want {
+				t.Errorf("isRetryableExitCode(%d) = %v, want %v", tc.exitcode, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package shell_local
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// runDryRun renders, for every script, the same interpolation Run would use
+// to execute it - the inline shebang, the exported environment, the script
+// body, and the final ExecuteCommand line - without invoking the
+// communicator. envVars is the map buildEnvVarMap already resolved for this
+// run; runDryRun never re-resolves secrets itself. The result is written to
+// config.DryRunOutputPath, or to the UI if that's unset - in both cases,
+// every configured secret value is replaced with a placeholder first, since
+// this output is meant to be read, diffed, and potentially checked into a
+// PR, unlike the runtime UI/log streams that packer.LogSecretFilter scrubs.
+func runDryRun(ui packer.Ui, config *Config, scripts []string, envVars map[string]string) error {
+	flattenedEnvVars := flattenEnvVarMap(config, envVars)
+	secretNames := configuredSecretNames(config)
+
+	var buf bytes.Buffer
+	for i, script := range scripts {
+		interpolatedCmds, err := createInterpolatedCommands(config, script, flattenedEnvVars)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "# --- script %d: %s ---\n", i+1, script)
+
+		if config.InlineShebang != "" {
+			fmt.Fprintf(&buf, "#!%s\n", config.InlineShebang)
+		}
+
+		writeExportedEnv(&buf, envVars, secretNames)
+
+		body, err := ioutil.ReadFile(script)
+		if err != nil {
+			fmt.Fprintf(&buf, "# (could not read script body: %s)\n", err)
+		} else {
+			buf.Write(body)
+			if len(body) > 0 && body[len(body)-1] != '\n' {
+				buf.WriteString("\n")
+			}
+		}
+
+		fmt.Fprintf(&buf, "# final command: %s\n", strings.Join(interpolatedCmds, " "))
+	}
+
+	// Belt-and-suspenders pass on top of writeExportedEnv's name-based
+	// masking, since a secret's value can also end up in the rendered
+	// command line via ExecuteCommand templating.
+	rendered := redactValues(buf.String(), configuredSecretValues(envVars, secretNames))
+
+	if config.DryRunOutputPath == "" {
+		ui.Say(rendered)
+		return nil
+	}
+
+	return ioutil.WriteFile(config.DryRunOutputPath, []byte(rendered), 0644)
+}
+
+func writeExportedEnv(w io.Writer, envVars map[string]string, secretNames map[string]bool) {
+	var keys []string
+	for k := range envVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := envVars[key]
+		if secretNames[key] {
+			value = redactedPlaceholder
+		}
+		fmt.Fprintf(w, "export %s=%q\n", key, value)
+	}
+}
@@ -0,0 +1,62 @@
+package shell_local
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// capturingUi implements packer.Ui by embedding a nil Ui and only
+// overriding Say, which is all runDryRun needs when DryRunOutputPath is
+// unset.
+type capturingUi struct {
+	packer.Ui
+	said []string
+}
+
+func (u *capturingUi) Say(line string) {
+	u.said = append(u.said, line)
+}
+
+func TestRunDryRun_redactsSecrets(t *testing.T) {
+	scriptPath := filepath.Join(t.TempDir(), "provision.sh")
+	if err := ioutil.WriteFile(scriptPath, []byte("#!/bin/sh\necho hello\n"), 0644); err != nil {
+		t.Fatalf("failed to write test script: %s", err)
+	}
+
+	config := &Config{
+		ExecuteCommand: []string{"{{.Vars}}", "{{.Command}}"},
+		EnvVarFormat:   "%s='%s' ",
+		Secrets: []SecretSource{
+			{Name: "API_TOKEN", Env: "PACKER_SHELL_LOCAL_TEST_TOKEN"},
+		},
+	}
+
+	envVars := map[string]string{
+		"API_TOKEN": "super-secret-value",
+		"OTHER_VAR": "not-a-secret",
+	}
+
+	ui := &capturingUi{}
+	if err := runDryRun(ui, config, []string{scriptPath}, envVars); err != nil {
+		t.Fatalf("runDryRun() error = %s", err)
+	}
+
+	if len(ui.said) != 1 {
+		t.Fatalf("expected exactly one Say call, got %d", len(ui.said))
+	}
+	rendered := ui.said[0]
+
+	if strings.Contains(rendered, "super-secret-value") {
+		t.Fatalf("rendered dry-run output leaked the secret value:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, redactedPlaceholder) {
+		t.Fatalf("rendered dry-run output did not contain the redaction placeholder:\n%s", rendered)
+	}
+	if !strings.Contains(rendered, "not-a-secret") {
+		t.Fatalf("rendered dry-run output should not redact non-secret values:\n%s", rendered)
+	}
+}
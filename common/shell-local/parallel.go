@@ -0,0 +1,69 @@
+package shell_local
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/packer/packer"
+)
+
+// scriptRunner executes a single script, including its retry policy. It's a
+// package variable, defaulting to runScriptWithRetry, so tests can swap in
+// a fake and exercise runScripts' concurrency, buffering, and error
+// aggregation without a real communicator.
+var scriptRunner = runScriptWithRetry
+
+// runScripts executes scripts, either sequentially (the default) or, when
+// config.Parallelism > 1, through a worker pool of that size. Every script
+// still goes through scriptRunner, so retry/backoff behavior is unchanged
+// by parallelism.
+func runScripts(ctx context.Context, ui packer.Ui, config *Config, scripts []string, flattenedEnvVars string, sink OutputSink, secretValues []string) error {
+	if config.Parallelism < 2 {
+		for _, script := range scripts {
+			if err := scriptRunner(ctx, ui, config, script, flattenedEnvVars, sink, secretValues); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	type result struct {
+		err   error
+		flush func()
+	}
+
+	sem := make(chan struct{}, config.Parallelism)
+	results := make(chan result, len(scripts))
+
+	for _, script := range scripts {
+		script := script
+
+		var scriptUi packer.Ui
+		flush := func() {}
+		if config.OutputMode == "interleaved" {
+			scriptUi = newTaggedUi(ui, script)
+		} else {
+			buffered := newBufferedUi(ui)
+			scriptUi = buffered
+			flush = buffered.Flush
+		}
+
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			err := scriptRunner(ctx, scriptUi, config, script, flattenedEnvVars, sink, secretValues)
+			results <- result{err: err, flush: flush}
+		}()
+	}
+
+	var merr *multierror.Error
+	for range scripts {
+		r := <-results
+		r.flush()
+		if r.err != nil {
+			merr = multierror.Append(merr, r.err)
+		}
+	}
+
+	return merr.ErrorOrNil()
+}
@@ -0,0 +1,148 @@
+package shell_local
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// syncCapturingUi is a thread-safe packer.Ui that records Say calls in the
+// order they arrive, for asserting on runScripts' output ordering.
+type syncCapturingUi struct {
+	packer.Ui
+
+	mu   sync.Mutex
+	said []string
+}
+
+func (u *syncCapturingUi) Say(line string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.said = append(u.said, line)
+}
+
+func (u *syncCapturingUi) lines() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make([]string, len(u.said))
+	copy(out, u.said)
+	return out
+}
+
+// withScriptRunner swaps scriptRunner for fn for the duration of the test.
+func withScriptRunner(t *testing.T, fn func(ctx context.Context, ui packer.Ui, config *Config, script, flattenedEnvVars string, sink OutputSink, secretValues []string) error) {
+	t.Helper()
+	original := scriptRunner
+	scriptRunner = fn
+	t.Cleanup(func() { scriptRunner = original })
+}
+
+func TestRunScripts_ParallelismHonorsConcurrencyCap(t *testing.T) {
+	const parallelism = 2
+	const scriptCount = 6
+
+	var mu sync.Mutex
+	current, max := 0, 0
+
+	withScriptRunner(t, func(ctx context.Context, ui packer.Ui, config *Config, script, flattenedEnvVars string, sink OutputSink, secretValues []string) error {
+		mu.Lock()
+		current++
+		if current > max {
+			max = current
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+		return nil
+	})
+
+	scripts := make([]string, scriptCount)
+	for i := range scripts {
+		scripts[i] = fmt.Sprintf("script-%d", i)
+	}
+
+	config := &Config{Parallelism: parallelism}
+	if err := runScripts(context.Background(), &syncCapturingUi{}, config, scripts, "", nil, nil); err != nil {
+		t.Fatalf("runScripts() error = %s", err)
+	}
+
+	if max > parallelism {
+		t.Fatalf("observed %d scripts running at once, want <= %d", max, parallelism)
+	}
+	if max < 2 {
+		t.Fatalf("observed only %d script running at once, want actual concurrency (>= 2)", max)
+	}
+}
+
+func TestRunScripts_AggregatesErrorsFromMultipleScripts(t *testing.T) {
+	withScriptRunner(t, func(ctx context.Context, ui packer.Ui, config *Config, script, flattenedEnvVars string, sink OutputSink, secretValues []string) error {
+		if script == "bad-1" || script == "bad-2" {
+			return fmt.Errorf("%s failed", script)
+		}
+		return nil
+	})
+
+	scripts := []string{"good-1", "bad-1", "good-2", "bad-2"}
+	config := &Config{Parallelism: 4}
+
+	err := runScripts(context.Background(), &syncCapturingUi{}, config, scripts, "", nil, nil)
+	if err == nil {
+		t.Fatal("runScripts() error = nil, want an aggregated error")
+	}
+	for _, want := range []string{"bad-1 failed", "bad-2 failed"} {
+		if !contains(err.Error(), want) {
+			t.Errorf("runScripts() error = %q, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestRunScripts_BufferedOutputIsNotInterleaved(t *testing.T) {
+	withScriptRunner(t, func(ctx context.Context, ui packer.Ui, config *Config, script, flattenedEnvVars string, sink OutputSink, secretValues []string) error {
+		if script == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		ui.Say(script + "-line1")
+		ui.Say(script + "-line2")
+		return nil
+	})
+
+	realUi := &syncCapturingUi{}
+	config := &Config{Parallelism: 2} // OutputMode defaults to "buffered"
+
+	if err := runScripts(context.Background(), realUi, config, []string{"slow", "fast"}, "", nil, nil); err != nil {
+		t.Fatalf("runScripts() error = %s", err)
+	}
+
+	lines := realUi.lines()
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4: %v", len(lines), lines)
+	}
+	// Each script's two lines must stay adjacent, whichever script's block
+	// comes first - buffering means a script's output is only flushed as
+	// one block once it finishes, never interleaved with another script's.
+	for i := 0; i < len(lines); i += 2 {
+		first, second := lines[i], lines[i+1]
+		firstScript := first[:len(first)-len("-line1")]
+		secondScript := second[:len(second)-len("-line2")]
+		if firstScript != secondScript {
+			t.Fatalf("lines interleaved: %v", lines)
+		}
+	}
+}
+
+func contains(s, substr string) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package shell_local
+
+import "strings"
+
+// redactedPlaceholder marks a configured secret's value wherever it would
+// otherwise appear in output meant to land somewhere durable - a dry-run
+// render, a log file, syslog - rather than just the transient UI stream
+// packer.LogSecretFilter already scrubs.
+const redactedPlaceholder = "<filtered>"
+
+// configuredSecretNames returns the env var names backed by a Secrets
+// entry, so callers can tell which values need redacting.
+func configuredSecretNames(config *Config) map[string]bool {
+	names := make(map[string]bool, len(config.Secrets))
+	for _, source := range config.Secrets {
+		if source.Name != "" {
+			names[source.Name] = true
+		}
+	}
+	return names
+}
+
+// configuredSecretValues returns the resolved value of every configured
+// secret present in envVars, so callers can redact them wherever they
+// appear verbatim, not just under their own name.
+func configuredSecretValues(envVars map[string]string, secretNames map[string]bool) []string {
+	values := make([]string, 0, len(secretNames))
+	for name := range secretNames {
+		if value := envVars[name]; value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// redactValues replaces every literal occurrence of each of values with
+// redactedPlaceholder.
+func redactValues(s string, values []string) string {
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, redactedPlaceholder)
+	}
+	return s
+}
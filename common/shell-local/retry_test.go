@@ -0,0 +1,46 @@
+package shell_local
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffWithJitter_bounds(t *testing.T) {
+	cases := []struct {
+		name     string
+		base     time.Duration
+		maxDelay time.Duration
+	}{
+		{name: "typical base, no cap", base: 2 * time.Second},
+		{name: "typical base, capped below base", base: 10 * time.Second, maxDelay: 3 * time.Second},
+		{name: "typical base, cap above base", base: time.Second, maxDelay: 5 * time.Second},
+		{name: "zero base defaults to one second", base: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			effectiveBase := tc.base
+			if effectiveBase <= 0 {
+				effectiveBase = time.Second
+			}
+			if tc.maxDelay > 0 && effectiveBase > tc.maxDelay {
+				effectiveBase = tc.maxDelay
+			}
+
+			// Sample many times since the delay includes random jitter.
+			for i := 0; i < 100; i++ {
+				got := backoffWithJitter(tc.base, tc.maxDelay)
+				if got < 0 {
+					t.Fatalf("backoffWithJitter(%s, %s) = %s, want >= 0", tc.base, tc.maxDelay, got)
+				}
+				if tc.maxDelay > 0 && got > tc.maxDelay {
+					t.Fatalf("backoffWithJitter(%s, %s) = %s, want <= max %s", tc.base, tc.maxDelay, got, tc.maxDelay)
+				}
+				upperBound := effectiveBase + effectiveBase/2
+				if got > upperBound {
+					t.Fatalf("backoffWithJitter(%s, %s) = %s, want <= %s", tc.base, tc.maxDelay, got, upperBound)
+				}
+			}
+		})
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer/common"
 	commonhelper "github.com/hashicorp/packer/helper/common"
@@ -67,43 +69,148 @@ func Run(ctx context.Context, ui packer.Ui, config *Config) (bool, error) {
 		defer os.Remove(tempScriptFileName)
 	}
 
-	// Create environment variables to set before executing the command
-	flattenedEnvVars, err := createFlattenedEnvVars(config)
+	// Resolve environment variables (including secrets) once, up front, so
+	// a DryRun render and a real run both see exactly one resolution pass.
+	envVars, err := buildEnvVarMap(ctx, config)
 	if err != nil {
 		return false, err
 	}
 
-	for _, script := range scripts {
-		interpolatedCmds, err := createInterpolatedCommands(config, script, flattenedEnvVars)
-		if err != nil {
+	if config.DryRun {
+		if err := runDryRun(ui, config, scripts, envVars); err != nil {
 			return false, err
 		}
-		ui.Say(fmt.Sprintf("Running local shell script: %s", script))
+		return true, nil
+	}
+
+	flattenedEnvVars := flattenEnvVarMap(config, envVars)
+	secretValues := configuredSecretValues(envVars, configuredSecretNames(config))
+
+	sink, err := buildOutputSink(config)
+	if err != nil {
+		return false, err
+	}
+	if sink != nil {
+		defer sink.Close()
+	}
+
+	if err := runScripts(ctx, ui, config, scripts, flattenedEnvVars, sink, secretValues); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
 
-		comm := &Communicator{
-			ExecuteCommand: interpolatedCmds,
+// runScriptWithRetry runs a single script, re-running it with exponential
+// backoff while its exit code is retryable and the retry budget (attempts
+// and overall timeout) isn't exhausted.
+func runScriptWithRetry(ctx context.Context, ui packer.Ui, config *Config, script, flattenedEnvVars string, sink OutputSink, secretValues []string) error {
+	if config.RetryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.RetryTimeout)
+		defer cancel()
+	}
+
+	// A negative MaxRetries is a misconfiguration, not a request to skip
+	// running the script entirely: clamp it so the script still runs once.
+	maxRetries := config.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	backoff := config.RetryBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			wait := backoffWithJitter(backoff, config.RetryBackoffMax)
+			log.Printf("[INFO] (shell-local): retrying %s in %s (attempt %d/%d)", script, wait, attempt, maxRetries)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return fmt.Errorf("Timed out waiting to retry script %s: %s", script, ctx.Err())
+			}
+			backoff *= 2
 		}
 
-		// The remoteCmd generated here isn't actually run, but it allows us to
-		// use the same interafce for the shell-local communicator as we use for
-		// the other communicators; ultimately, this command is just used for
-		// buffers and for reading the final exit status.
-		flattenedCmd := strings.Join(interpolatedCmds, " ")
-		cmd := &packer.RemoteCmd{Command: flattenedCmd}
-		log.Printf("[INFO] (shell-local): starting local command: %s", flattenedCmd)
-		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
-			return false, fmt.Errorf(
-				"Error executing script: %s\n\n"+
-					"Please see output above for more information.",
-				script)
+		exitStatus, err := runScriptOnce(ctx, ui, config, script, flattenedEnvVars, sink, secretValues)
+		if err != nil {
+			return err
 		}
 
-		if err := config.ValidExitCode(cmd.ExitStatus()); err != nil {
-			return false, err
+		validErr := config.ValidExitCode(exitStatus)
+		if validErr == nil {
+			return nil
+		}
+		lastErr = validErr
+
+		if !config.isRetryableExitCode(exitStatus) {
+			return lastErr
 		}
 	}
 
-	return true, nil
+	return lastErr
+}
+
+// backoffWithJitter returns a delay in [0.5*base, 1.5*base), capped at max
+// when max is non-zero.
+func backoffWithJitter(base, maxDelay time.Duration) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+	if maxDelay > 0 && base > maxDelay {
+		base = maxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(base))) - base/2
+	delay := base + jitter
+	if delay < 0 {
+		delay = base
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// runScriptOnce interpolates and executes a script exactly once, returning
+// its exit status.
+func runScriptOnce(ctx context.Context, ui packer.Ui, config *Config, script, flattenedEnvVars string, sink OutputSink, secretValues []string) (int, error) {
+	interpolatedCmds, err := createInterpolatedCommands(config, script, flattenedEnvVars)
+	if err != nil {
+		return 0, err
+	}
+	ui.Say(fmt.Sprintf("Running local shell script: %s", script))
+
+	comm := &Communicator{
+		ExecuteCommand: interpolatedCmds,
+	}
+
+	// The remoteCmd generated here isn't actually run, but it allows us to
+	// use the same interafce for the shell-local communicator as we use for
+	// the other communicators; ultimately, this command is just used for
+	// buffers and for reading the final exit status.
+	flattenedCmd := strings.Join(interpolatedCmds, " ")
+	cmd := &packer.RemoteCmd{Command: flattenedCmd}
+
+	runUi := ui
+	if sink != nil {
+		runUi = newSinkUi(ui, sink, map[string]string{
+			"build":  config.PackerBuildName,
+			"script": script,
+		}, secretValues)
+	}
+
+	log.Printf("[INFO] (shell-local): starting local command: %s", flattenedCmd)
+	if err := cmd.RunWithUi(ctx, comm, runUi); err != nil {
+		return 0, fmt.Errorf(
+			"Error executing script: %s\n\n"+
+				"Please see output above for more information.",
+			script)
+	}
+
+	return cmd.ExitStatus(), nil
 }
 
 func createInlineScriptFile(config *Config) (string, error) {
@@ -170,8 +277,10 @@ func createInterpolatedCommands(config *Config, script string, flattenedEnvVars
 	return interpolatedCmds, nil
 }
 
-func createFlattenedEnvVars(config *Config) (string, error) {
-	flattened := ""
+// buildEnvVarMap resolves every environment variable shell-local will
+// expose to a script: the Packer-provided vars, then resolved Secrets, then
+// the user's Vars, each overriding any earlier value with the same name.
+func buildEnvVarMap(ctx context.Context, config *Config) (map[string]string, error) {
 	envVars := make(map[string]string)
 
 	// Always available Packer provided env vars
@@ -192,6 +301,16 @@ func createFlattenedEnvVars(config *Config) (string, error) {
 		envVars["PACKER_HTTP_PORT"] = httpPort
 	}
 
+	// resolve and register configured secrets before interpolating Vars,
+	// so a Vars entry can still override a secret's env var name if needed
+	secretVars, err := resolveSecrets(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range secretVars {
+		envVars[name] = value
+	}
+
 	// interpolate environment variables
 	config.ctx.Data = &EnvVarsTemplate{
 		WinRMPassword: getWinRMPassword(config.PackerBuildName),
@@ -200,7 +319,7 @@ func createFlattenedEnvVars(config *Config) (string, error) {
 	for _, envVar := range config.Vars {
 		envVar, err := interpolate.Render(envVar, &config.ctx)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		// Split vars into key/value components
 		keyValue := strings.SplitN(envVar, "=", 2)
@@ -209,6 +328,14 @@ func createFlattenedEnvVars(config *Config) (string, error) {
 		envVars[keyValue[0]] = strings.Replace(keyValue[1], "'", `'"'"'`, -1)
 	}
 
+	return envVars, nil
+}
+
+// flattenEnvVarMap renders an already-resolved env var map (see
+// buildEnvVarMap) using config.EnvVarFormat, in sorted key order.
+func flattenEnvVarMap(config *Config, envVars map[string]string) string {
+	flattened := ""
+
 	// Create a list of env var keys in sorted order
 	var keys []string
 	for k := range envVars {
@@ -219,7 +346,7 @@ func createFlattenedEnvVars(config *Config) (string, error) {
 	for _, key := range keys {
 		flattened += fmt.Sprintf(config.EnvVarFormat, key, envVars[key])
 	}
-	return flattened, nil
+	return flattened
 }
 
 func getWinRMPassword(buildName string) string {
@@ -0,0 +1,55 @@
+// Package awssecretsmanager registers the "aws_secrets_manager" shell-local
+// secret provider kind. Import it for its init side effect - typically as a
+// blank import - from any provisioner or post-processor binary that wants
+// shell-local's `secrets` config block to be able to read from AWS Secrets
+// Manager:
+//
+//	import _ "github.com/hashicorp/packer/common/shell-local/secretproviders/awssecretsmanager"
+//
+// It lives outside common/shell-local itself so that building shell-local
+// without AWS secrets doesn't pull in aws-sdk-go.
+package awssecretsmanager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	shell_local "github.com/hashicorp/packer/common/shell-local"
+)
+
+func init() {
+	shell_local.RegisterSecretProviderFactory("aws_secrets_manager", newProvider)
+}
+
+// provider reads the current value of an AWS Secrets Manager secret, by
+// name or ARN.
+type provider struct {
+	secretID string
+}
+
+func newProvider(secretID string) (shell_local.SecretProvider, error) {
+	return &provider{secretID: secretID}, nil
+}
+
+func (p *provider) Resolve(ctx context.Context) (string, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("Error creating AWS session: %s", err)
+	}
+
+	svc := secretsmanager.New(sess)
+	out, err := svc.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(p.secretID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error reading AWS Secrets Manager secret %q: %s", p.secretID, err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("AWS Secrets Manager secret %q has no string value", p.secretID)
+	}
+	return *out.SecretString, nil
+}
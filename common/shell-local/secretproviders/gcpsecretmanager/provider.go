@@ -0,0 +1,52 @@
+// Package gcpsecretmanager registers the "gcp_secret_manager" shell-local
+// secret provider kind. Import it for its init side effect - typically as a
+// blank import - from any provisioner or post-processor binary that wants
+// shell-local's `secrets` config block to be able to read from GCP Secret
+// Manager:
+//
+//	import _ "github.com/hashicorp/packer/common/shell-local/secretproviders/gcpsecretmanager"
+//
+// It lives outside common/shell-local itself so that building shell-local
+// without GCP secrets doesn't pull in cloud.google.com/go/secretmanager and
+// its genproto dependencies.
+package gcpsecretmanager
+
+import (
+	"context"
+	"fmt"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+
+	shell_local "github.com/hashicorp/packer/common/shell-local"
+)
+
+func init() {
+	shell_local.RegisterSecretProviderFactory("gcp_secret_manager", newProvider)
+}
+
+// provider reads the payload of a GCP Secret Manager secret version, e.g.
+// "projects/my-project/secrets/api-token/versions/latest".
+type provider struct {
+	name string
+}
+
+func newProvider(name string) (shell_local.SecretProvider, error) {
+	return &provider{name: name}, nil
+}
+
+func (p *provider) Resolve(ctx context.Context) (string, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Error creating GCP Secret Manager client: %s", err)
+	}
+	defer client.Close()
+
+	result, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{
+		Name: p.name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error reading GCP Secret Manager secret %q: %s", p.name, err)
+	}
+	return string(result.Payload.Data), nil
+}
@@ -0,0 +1,70 @@
+// Package vault registers the "vault" shell-local secret provider kind.
+// Import it for its init side effect - typically as a blank import - from
+// any provisioner or post-processor binary that wants shell-local's
+// `secrets` config block to be able to read from Vault:
+//
+//	import _ "github.com/hashicorp/packer/common/shell-local/secretproviders/vault"
+//
+// It lives outside common/shell-local itself so that building shell-local
+// without Vault secrets doesn't pull in hashicorp/vault/api.
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+
+	shell_local "github.com/hashicorp/packer/common/shell-local"
+)
+
+func init() {
+	shell_local.RegisterSecretProviderFactory("vault", newProvider)
+}
+
+// provider reads a single key out of a Vault secret. path is
+// "<secret path>#<key>"; if no "#<key>" suffix is given, "value" is used.
+type provider struct {
+	path string
+}
+
+func newProvider(path string) (shell_local.SecretProvider, error) {
+	return &provider{path: path}, nil
+}
+
+func (p *provider) Resolve(ctx context.Context) (string, error) {
+	path, key := p.path, "value"
+	if idx := strings.LastIndex(p.path, "#"); idx != -1 {
+		path, key = p.path[:idx], p.path[idx+1:]
+	}
+
+	client, err := vaultapi.NewClient(vaultapi.DefaultConfig())
+	if err != nil {
+		return "", fmt.Errorf("Error creating Vault client: %s", err)
+	}
+
+	secret, err := client.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading Vault secret %q: %s", path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return "", fmt.Errorf("Vault secret %q not found", path)
+	}
+
+	// KV v2 nests the actual values under "data".
+	data := secret.Data
+	if nested, ok := secret.Data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("Vault secret %q has no key %q", path, key)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("Vault secret %q key %q is not a string", path, key)
+	}
+	return str, nil
+}
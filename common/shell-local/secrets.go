@@ -0,0 +1,110 @@
+package shell_local
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// SecretSource names one secret to resolve before scripts run and the
+// environment variable it should be exposed as. Exactly one of Vault,
+// AWSSecretsManager, GCPSecretManager, or Env should be set; they're
+// checked in that order.
+type SecretSource struct {
+	// Name is the environment variable the resolved secret is exposed as.
+	Name string `mapstructure:"name"`
+
+	// Vault is a Vault secret path, e.g. "secret/data/ci#api_token".
+	Vault string `mapstructure:"vault"`
+
+	// AWSSecretsManager is an AWS Secrets Manager secret ID or ARN.
+	AWSSecretsManager string `mapstructure:"aws_secrets_manager"`
+
+	// GCPSecretManager is a GCP Secret Manager resource name, e.g.
+	// "projects/my-project/secrets/api-token/versions/latest".
+	GCPSecretManager string `mapstructure:"gcp_secret_manager"`
+
+	// Env reads the secret from this process's own environment, so that
+	// secrets already injected by CI can still be registered with
+	// LogSecretFilter and renamed for the script.
+	Env string `mapstructure:"env"`
+}
+
+// SecretProvider resolves a single secret value. Implementations should do
+// whatever network I/O they need up front; Resolve is only called once per
+// SecretSource, at the start of Run.
+type SecretProvider interface {
+	Resolve(ctx context.Context) (string, error)
+}
+
+// SecretProviderFactory builds a SecretProvider for the raw value
+// configured for its kind (a Vault path, an AWS Secrets Manager ID, ...).
+type SecretProviderFactory func(value string) (SecretProvider, error)
+
+var secretProviderFactories = map[string]SecretProviderFactory{}
+
+// RegisterSecretProviderFactory makes a secret kind (e.g. "vault") usable
+// from a "secrets" config block. common/shell-local deliberately doesn't
+// register vault/aws_secrets_manager/gcp_secret_manager itself - each of
+// those pulls in a full cloud SDK, which would otherwise be a compile-time
+// and dependency-graph cost on every build that uses shell-local, even ones
+// that never touch secrets. A provisioner or post-processor that wants a
+// given kind imports the matching common/shell-local/secretproviders/*
+// package for its init side effect.
+func RegisterSecretProviderFactory(kind string, factory SecretProviderFactory) {
+	secretProviderFactories[kind] = factory
+}
+
+func buildRegisteredProvider(kind, value string) (SecretProvider, error) {
+	factory, ok := secretProviderFactories[kind]
+	if !ok {
+		return nil, fmt.Errorf(
+			"secret kind %q is not registered; import common/shell-local/secretproviders/%s for its init side effect",
+			kind, strings.ReplaceAll(kind, "_", ""))
+	}
+	return factory(value)
+}
+
+// provider returns the SecretProvider this source describes.
+func (s SecretSource) provider() (SecretProvider, error) {
+	switch {
+	case s.Vault != "":
+		return buildRegisteredProvider("vault", s.Vault)
+	case s.AWSSecretsManager != "":
+		return buildRegisteredProvider("aws_secrets_manager", s.AWSSecretsManager)
+	case s.GCPSecretManager != "":
+		return buildRegisteredProvider("gcp_secret_manager", s.GCPSecretManager)
+	case s.Env != "":
+		return &envSecretProvider{key: s.Env}, nil
+	default:
+		return nil, fmt.Errorf("secret %q: one of vault, aws_secrets_manager, gcp_secret_manager, or env must be set", s.Name)
+	}
+}
+
+// resolveSecrets resolves every configured secret, registers each value
+// with packer.LogSecretFilter, and returns them keyed by the environment
+// variable name they should be exposed as.
+func resolveSecrets(ctx context.Context, config *Config) (map[string]string, error) {
+	resolved := make(map[string]string, len(config.Secrets))
+	for _, source := range config.Secrets {
+		if source.Name == "" {
+			return nil, fmt.Errorf("secrets: \"name\" is required for every entry")
+		}
+
+		provider, err := source.provider()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := provider.Resolve(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error resolving secret %q: %s", source.Name, err)
+		}
+
+		packer.LogSecretFilter.Set(value)
+		resolved[source.Name] = value
+	}
+	return resolved, nil
+}
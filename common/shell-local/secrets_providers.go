@@ -0,0 +1,24 @@
+package shell_local
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// envSecretProvider reads a secret out of this process's own environment.
+// It's the one SecretProvider kind built into common/shell-local (it is
+// constructed directly, not through RegisterSecretProviderFactory) - it only needs the standard library, so it carries none of the
+// dependency-graph cost that the vault/aws/gcp providers in
+// common/shell-local/secretproviders/* do.
+type envSecretProvider struct {
+	key string
+}
+
+func (p *envSecretProvider) Resolve(ctx context.Context) (string, error) {
+	value, ok := os.LookupEnv(p.key)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not set", p.key)
+	}
+	return value, nil
+}
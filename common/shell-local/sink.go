@@ -0,0 +1,173 @@
+package shell_local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// OutputSink receives one line of provisioner output at a time, tagged with
+// its level ("stdout" or "stderr") and arbitrary caller-supplied metadata
+// (build name, script path, and so on). Run serializes calls to a sink
+// returned by buildOutputSink (via syncSink), so individual implementations
+// don't need to be safe for concurrent use themselves.
+type OutputSink interface {
+	Write(level, line string, meta map[string]string) error
+	Close() error
+}
+
+// syncSink serializes access to an OutputSink so that it can be shared
+// across the goroutines spawned when Parallelism > 1.
+type syncSink struct {
+	mu   sync.Mutex
+	sink OutputSink
+}
+
+func (s *syncSink) Write(level, line string, meta map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sink.Write(level, line, meta)
+}
+
+func (s *syncSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sink.Close()
+}
+
+// jsonLineSink writes each line as a single JSON object to an append-only
+// file, so that CI systems can ingest provisioner output without scraping
+// the interleaved UI stream.
+type jsonLineSink struct {
+	f *os.File
+}
+
+type jsonLineRecord struct {
+	Time  string            `json:"time"`
+	Level string            `json:"level"`
+	Line  string            `json:"line"`
+	Meta  map[string]string `json:"meta,omitempty"`
+}
+
+func newJSONLineSink(path string) (OutputSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening log file %q: %s", path, err)
+	}
+	return &jsonLineSink{f: f}, nil
+}
+
+func (s *jsonLineSink) Write(level, line string, meta map[string]string) error {
+	record := jsonLineRecord{
+		Time:  time.Now().UTC().Format(time.RFC3339Nano),
+		Level: level,
+		Line:  line,
+		Meta:  meta,
+	}
+	enc, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(s.f, string(enc))
+	return err
+}
+
+func (s *jsonLineSink) Close() error {
+	return s.f.Close()
+}
+
+// textLineSink writes each line as a plain, human-readable log line. It
+// backs LogFormat == "text".
+type textLineSink struct {
+	f *os.File
+}
+
+func newTextLineSink(path string) (OutputSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening log file %q: %s", path, err)
+	}
+	return &textLineSink{f: f}, nil
+}
+
+func (s *textLineSink) Write(level, line string, meta map[string]string) error {
+	_, err := fmt.Fprintf(s.f, "%s [%s] (%s) %s\n",
+		time.Now().UTC().Format(time.RFC3339), level, meta["script"], line)
+	return err
+}
+
+func (s *textLineSink) Close() error {
+	return s.f.Close()
+}
+
+// multiSink fans a single Write out to every configured sink, so LogFile and
+// SyslogAddr can be used together rather than one silently winning.
+type multiSink struct {
+	sinks []OutputSink
+}
+
+func (m *multiSink) Write(level, line string, meta map[string]string) error {
+	var result error
+	for _, sink := range m.sinks {
+		if err := sink.Write(level, line, meta); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+func (m *multiSink) Close() error {
+	var result error
+	for _, sink := range m.sinks {
+		if err := sink.Close(); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+// buildOutputSink constructs the OutputSink(s) configured for this run, if
+// any. It returns a nil sink (and nil error) when no sink is configured, so
+// callers can fall back to the existing UI-only behavior untouched. LogFile
+// and SyslogAddr may both be set, in which case every line is written to
+// both.
+func buildOutputSink(config *Config) (OutputSink, error) {
+	var sinks []OutputSink
+
+	if config.LogFile != "" {
+		var (
+			fileSink OutputSink
+			err      error
+		)
+		if config.LogFormat == "text" {
+			fileSink, err = newTextLineSink(config.LogFile)
+		} else {
+			fileSink, err = newJSONLineSink(config.LogFile)
+		}
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, fileSink)
+	}
+
+	if config.SyslogAddr != "" {
+		syslogSink, err := newSyslogSink(config.SyslogAddr)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, syslogSink)
+	}
+
+	switch len(sinks) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &syncSink{sink: sinks[0]}, nil
+	default:
+		return &syncSink{sink: &multiSink{sinks: sinks}}, nil
+	}
+}
@@ -0,0 +1,147 @@
+package shell_local
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJSONLineSink_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	sink, err := newJSONLineSink(path)
+	if err != nil {
+		t.Fatalf("newJSONLineSink() error = %s", err)
+	}
+	if err := sink.Write("stdout", "hello", map[string]string{"script": "a.sh"}); err != nil {
+		t.Fatalf("Write() error = %s", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %s", err)
+	}
+
+	var record jsonLineRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("the written line didn't decode as JSON: %s\n%s", err, data)
+	}
+	if record.Level != "stdout" || record.Line != "hello" || record.Meta["script"] != "a.sh" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+}
+
+func TestTextLineSink_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	sink, err := newTextLineSink(path)
+	if err != nil {
+		t.Fatalf("newTextLineSink() error = %s", err)
+	}
+	if err := sink.Write("stderr", "boom", map[string]string{"script": "a.sh"}); err != nil {
+		t.Fatalf("Write() error = %s", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %s", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %s", err)
+	}
+	if !strings.Contains(string(data), "[stderr] (a.sh) boom") {
+		t.Fatalf("unexpected text line: %q", data)
+	}
+}
+
+// recordingSink is a fake OutputSink that records every call it receives,
+// for asserting on fan-out and serialization behavior.
+type recordingSink struct {
+	mu     sync.Mutex
+	writes []string
+	closed bool
+}
+
+func (s *recordingSink) Write(level, line string, meta map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, line)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// erroringSink is a fake OutputSink whose every call fails, for asserting
+// that multiSink aggregates rather than swallows errors.
+type erroringSink struct{}
+
+func (erroringSink) Write(level, line string, meta map[string]string) error {
+	return fmt.Errorf("write failed")
+}
+
+func (erroringSink) Close() error {
+	return fmt.Errorf("close failed")
+}
+
+func TestMultiSink_fansOutToEverySink(t *testing.T) {
+	a := &recordingSink{}
+	b := &recordingSink{}
+	m := &multiSink{sinks: []OutputSink{a, b}}
+
+	if err := m.Write("stdout", "hello", nil); err != nil {
+		t.Fatalf("Write() error = %s", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %s", err)
+	}
+
+	for name, s := range map[string]*recordingSink{"a": a, "b": b} {
+		if len(s.writes) != 1 || s.writes[0] != "hello" {
+			t.Errorf("sink %s: writes = %v, want [\"hello\"]", name, s.writes)
+		}
+		if !s.closed {
+			t.Errorf("sink %s: not closed", name)
+		}
+	}
+}
+
+func TestMultiSink_aggregatesErrorsFromEverySink(t *testing.T) {
+	m := &multiSink{sinks: []OutputSink{erroringSink{}, erroringSink{}}}
+
+	err := m.Write("stdout", "hello", nil)
+	if err == nil {
+		t.Fatal("Write() error = nil, want an aggregated error")
+	}
+	if got := strings.Count(err.Error(), "write failed"); got != 2 {
+		t.Fatalf("Write() error = %q, want both sinks' errors", err)
+	}
+}
+
+func TestSyncSink_serializesConcurrentWrites(t *testing.T) {
+	rec := &recordingSink{}
+	s := &syncSink{sink: rec}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.Write("stdout", fmt.Sprintf("line-%d", i), nil)
+		}(i)
+	}
+	wg.Wait()
+
+	if len(rec.writes) != 50 {
+		t.Fatalf("got %d writes, want 50", len(rec.writes))
+	}
+}
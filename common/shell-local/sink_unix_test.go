@@ -0,0 +1,40 @@
+// +build !windows
+
+package shell_local
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatRFC5424(t *testing.T) {
+	msg := formatRFC5424(syslogSeverityErr, "myhost", map[string]string{
+		"build":  "qemu.ubuntu",
+		"script": "provision.sh",
+	}, "boom")
+
+	wantPri := "<" + "27>1 " // facility 1 (user) * 8 + severity 3 = 27
+	if !strings.HasPrefix(msg, wantPri) {
+		t.Fatalf("formatRFC5424() = %q, want prefix %q", msg, wantPri)
+	}
+	if !strings.Contains(msg, " myhost packer-shell-local ") {
+		t.Fatalf("formatRFC5424() = %q, missing hostname/app-name fields", msg)
+	}
+	if !strings.Contains(msg, "provision.sh") {
+		t.Fatalf("formatRFC5424() = %q, missing MSGID", msg)
+	}
+	if !strings.Contains(msg, `build="qemu.ubuntu"`) {
+		t.Fatalf("formatRFC5424() = %q, missing structured data", msg)
+	}
+	if !strings.HasSuffix(msg, "boom\n") {
+		t.Fatalf("formatRFC5424() = %q, want it to end with the MSG", msg)
+	}
+}
+
+func TestFormatRFC5424_noMeta(t *testing.T) {
+	msg := formatRFC5424(syslogSeverityInfo, "myhost", nil, "hello")
+
+	if !strings.Contains(msg, " - - hello\n") {
+		t.Fatalf("formatRFC5424() = %q, want NILVALUE MSGID and STRUCTURED-DATA", msg)
+	}
+}
@@ -0,0 +1,12 @@
+// +build windows
+
+package shell_local
+
+import "fmt"
+
+// Windows has no local syslog/journald socket to forward to, so a
+// SyslogAddr configuration is rejected with a clear error rather than
+// silently doing nothing.
+func newSyslogSink(addr string) (OutputSink, error) {
+	return nil, fmt.Errorf("syslog_address is not supported on windows")
+}
@@ -0,0 +1,126 @@
+package shell_local
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// sinkUi wraps a packer.Ui so that every line the script prints is also
+// forwarded to an OutputSink, tagged with the metadata for this run. It
+// otherwise behaves exactly like the wrapped Ui. secretValues is redacted
+// out of every forwarded line before the sink ever sees it: a script that
+// echoes a resolved secret (an env dump, `set -x`, an error message) would
+// otherwise write it verbatim into LogFile/SyslogAddr, durable artifacts
+// packer.LogSecretFilter's UI/log scrubbing never reaches.
+type sinkUi struct {
+	packer.Ui
+	sink         OutputSink
+	meta         map[string]string
+	secretValues []string
+}
+
+func newSinkUi(ui packer.Ui, sink OutputSink, meta map[string]string, secretValues []string) packer.Ui {
+	if sink == nil {
+		return ui
+	}
+	return &sinkUi{Ui: ui, sink: sink, meta: meta, secretValues: secretValues}
+}
+
+func (u *sinkUi) Message(line string) {
+	u.forward("stdout", line)
+	u.Ui.Message(line)
+}
+
+func (u *sinkUi) Say(line string) {
+	u.forward("stdout", line)
+	u.Ui.Say(line)
+}
+
+func (u *sinkUi) Error(line string) {
+	u.forward("stderr", line)
+	u.Ui.Error(line)
+}
+
+func (u *sinkUi) forward(level, line string) {
+	line = redactValues(line, u.secretValues)
+	if err := u.sink.Write(level, line, u.meta); err != nil {
+		log.Printf("[ERROR] (shell-local): error writing to log sink: %s", err)
+	}
+}
+
+// uiCall records a single deferred call to a packer.Ui method, so a
+// bufferedUi can replay it later in the order it was recorded.
+type uiCall struct {
+	method string
+	line   string
+}
+
+// bufferedUi buffers Say/Message/Error calls instead of emitting them
+// immediately. Used in OutputMode "buffered" (the default) so that
+// concurrently-run scripts don't interleave their output; each script's
+// buffer is flushed to the real Ui once that script finishes.
+type bufferedUi struct {
+	packer.Ui
+
+	mu    sync.Mutex
+	calls []uiCall
+}
+
+func newBufferedUi(ui packer.Ui) *bufferedUi {
+	return &bufferedUi{Ui: ui}
+}
+
+func (u *bufferedUi) Say(line string)     { u.record("Say", line) }
+func (u *bufferedUi) Message(line string) { u.record("Message", line) }
+func (u *bufferedUi) Error(line string)   { u.record("Error", line) }
+
+func (u *bufferedUi) record(method, line string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.calls = append(u.calls, uiCall{method: method, line: line})
+}
+
+// Flush replays every buffered call, in recorded order, to the wrapped Ui.
+func (u *bufferedUi) Flush() {
+	u.mu.Lock()
+	calls := u.calls
+	u.calls = nil
+	u.mu.Unlock()
+
+	for _, c := range calls {
+		switch c.method {
+		case "Say":
+			u.Ui.Say(c.line)
+		case "Message":
+			u.Ui.Message(c.line)
+		case "Error":
+			u.Ui.Error(c.line)
+		}
+	}
+}
+
+// taggedUi prefixes every line with a tag (typically the script name), so
+// that interleaved parallel output stays attributable.
+type taggedUi struct {
+	packer.Ui
+	tag string
+}
+
+func newTaggedUi(ui packer.Ui, tag string) *taggedUi {
+	return &taggedUi{Ui: ui, tag: tag}
+}
+
+func (u *taggedUi) Say(line string) {
+	u.Ui.Say(fmt.Sprintf("[%s] %s", u.tag, line))
+}
+
+func (u *taggedUi) Message(line string) {
+	u.Ui.Message(fmt.Sprintf("[%s] %s", u.tag, line))
+}
+
+func (u *taggedUi) Error(line string) {
+	u.Ui.Error(fmt.Sprintf("[%s] %s", u.tag, line))
+}